@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// rateLimitCodes are the Graph API error codes that mean "back off and
+// retry" rather than "this uid is invalid".
+var rateLimitCodes = map[int]bool{
+	4:   true, // Application request limit reached
+	17:  true, // User request limit reached
+	32:  true, // Page request limit reached
+	613: true, // Calls to this api have exceeded the rate limit
+}
+
+const (
+	backoffBase = 2 * time.Second
+	backoffMax  = 5 * time.Minute
+)
+
+// backoff implements exponential backoff with jitter, shared across every
+// worker in the pool so a single rate-limit hit pauses all of them instead
+// of just the worker that hit it.
+type backoff struct {
+	mu      sync.Mutex
+	attempt int
+	until   time.Time
+}
+
+// trip records a rate-limit hit and returns how long the pool should pause.
+func (b *backoff) trip() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempt++
+	d := backoffBase * time.Duration(math.Pow(2, float64(b.attempt-1)))
+	if d > backoffMax {
+		d = backoffMax
+	}
+	d += time.Duration(rand.Int63n(int64(d)/2 + 1))
+
+	b.until = time.Now().Add(d)
+	return d
+}
+
+// reset clears the backoff state after a successful request.
+func (b *backoff) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempt = 0
+	b.until = time.Time{}
+}
+
+// wait blocks until any outstanding backoff window has elapsed.
+func (b *backoff) wait() {
+	b.mu.Lock()
+	until := b.until
+	b.mu.Unlock()
+
+	if d := time.Until(until); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// isRateLimited reports whether err is a Graph error carrying one of the
+// rate-limit codes.
+func isRateLimited(err error) bool {
+	ge, ok := err.(*graphError)
+	if !ok {
+		return false
+	}
+	return rateLimitCodes[ge.Code]
+}
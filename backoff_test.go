@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffTripGrowsExponentially(t *testing.T) {
+	b := new(backoff)
+
+	for i := 0; i < 4; i++ {
+		// trip's jitter can add up to half of the base delay, so compare
+		// against the unjittered floor for this attempt rather than
+		// requiring strict growth of the jittered result.
+		floor := backoffBase * time.Duration(int64(1)<<uint(i))
+		if floor > backoffMax {
+			floor = backoffMax
+		}
+
+		d := b.trip()
+		if d < floor || d > floor+floor/2 {
+			t.Fatalf("attempt %d: trip() = %v, want in [%v, %v]", i, d, floor, floor+floor/2)
+		}
+	}
+}
+
+func TestBackoffTripCapsAtMax(t *testing.T) {
+	b := new(backoff)
+	for i := 0; i < 20; i++ {
+		if d := b.trip(); d > backoffMax+backoffMax/2 {
+			t.Fatalf("trip() = %v, want <= %v", d, backoffMax+backoffMax/2)
+		}
+	}
+}
+
+func TestBackoffResetClearsWait(t *testing.T) {
+	b := new(backoff)
+	b.trip()
+	b.reset()
+
+	start := time.Now()
+	b.wait()
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Fatalf("wait() blocked for %v after reset", elapsed)
+	}
+}
+
+func TestIsRateLimited(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limit code", &graphError{Code: 4}, true},
+		{"non rate limit code", &graphError{Code: 100}, false},
+		{"not a graph error", errors.New("boom"), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isRateLimited(c.err); got != c.want {
+				t.Errorf("isRateLimited(%v) = %v, want %v", c.err, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// browserFetcher scrapes a user's public profile by driving a headless
+// Chromium session, for uids the Graph API rejects outright (missing or
+// expired token, permission errors).
+type browserFetcher struct {
+	pw      *playwright.Playwright
+	browser playwright.Browser
+}
+
+// newBrowserFetcher installs the Chromium build playwright-go needs (a
+// no-op if it's already present) and launches it headless. The returned
+// fetcher owns the browser and driver process until Close is called.
+func newBrowserFetcher() (*browserFetcher, error) {
+	if err := playwright.Install(&playwright.RunOptions{Browsers: []string{"chromium"}}); err != nil {
+		return nil, fmt.Errorf("installing playwright browsers: %w", err)
+	}
+
+	pw, err := playwright.Run()
+	if err != nil {
+		return nil, fmt.Errorf("starting playwright: %w", err)
+	}
+
+	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
+		Headless: playwright.Bool(true),
+	})
+	if err != nil {
+		pw.Stop()
+		return nil, fmt.Errorf("launching chromium: %w", err)
+	}
+
+	return &browserFetcher{pw: pw, browser: browser}, nil
+}
+
+// Close shuts down the browser and the Playwright driver process.
+func (b *browserFetcher) Close() error {
+	if err := b.browser.Close(); err != nil {
+		return err
+	}
+	return b.pw.Stop()
+}
+
+// Fetch loads https://www.facebook.com/{uid} in a fresh browser context
+// and scrapes the fields the rendered DOM exposes without a token: name,
+// username, canonical link, and locale.
+func (b *browserFetcher) Fetch(uid uint64) (u *User, err error) {
+	ctx, err := b.browser.NewContext()
+	if err != nil {
+		return nil, fmt.Errorf("new browser context: %w", err)
+	}
+	defer ctx.Close()
+
+	page, err := ctx.NewPage()
+	if err != nil {
+		return nil, fmt.Errorf("new page: %w", err)
+	}
+
+	url := fmt.Sprintf("https://www.facebook.com/%d", uid)
+	if _, err = page.Goto(url, playwright.PageGotoOptions{
+		Timeout: playwright.Float(30000),
+	}); err != nil {
+		return nil, fmt.Errorf("loading %s: %w", url, err)
+	}
+
+	name, err := pageName(page)
+	if err != nil {
+		return nil, err
+	}
+
+	locale, _ := page.GetAttribute("html", "lang")
+	canonical, _ := page.Locator(`link[rel="canonical"]`).GetAttribute("href")
+
+	username := strings.TrimSuffix(strings.TrimPrefix(canonical,
+		"https://www.facebook.com/"), "/")
+
+	u = &User{
+		Id:       uid,
+		Name:     name,
+		Link:     canonical,
+		Username: username,
+		Locale:   locale,
+	}
+	return
+}
+
+// pageName recovers the profile's display name. <title> is typically
+// "Name | Facebook" (or a login-wall string), not the name itself, so this
+// prefers the og:title meta tag, which Facebook renders as the bare name,
+// and only falls back to stripping the " | Facebook" suffix off <title>
+// if that meta tag is missing.
+func pageName(page playwright.Page) (string, error) {
+	if og, err := page.GetAttribute(`meta[property="og:title"]`, "content"); err == nil && og != "" {
+		return og, nil
+	}
+
+	title, err := page.Title()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(title, " | Facebook"), nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Fetcher retrieves a single user's profile from some backend — the
+// Graph API, a headless browser, or a chain of the two.
+type Fetcher interface {
+	Fetch(uid uint64) (*User, error)
+}
+
+// newFetcher builds the Fetcher named by name: "graph", "browser", or
+// "chain" (Graph first, falling back to the browser on the error codes
+// in chainFallbackCodes). tokens may be nil, in which case graphFetcher
+// makes anonymous requests.
+func newFetcher(name string, tokens *tokenPool) (Fetcher, error) {
+	switch name {
+	case "graph":
+		return graphFetcher{tokens: tokens}, nil
+	case "browser":
+		return newBrowserFetcher()
+	case "chain":
+		bf, err := newBrowserFetcher()
+		if err != nil {
+			return nil, err
+		}
+		return chainFetcher{primary: graphFetcher{tokens: tokens}, secondary: bf}, nil
+	default:
+		return nil, fmt.Errorf("unknown fetcher %q", name)
+	}
+}
+
+// graphFetcher fetches users via the Facebook Graph API, rotating
+// through tokens when one is supplied.
+type graphFetcher struct {
+	tokens *tokenPool
+}
+
+// Fetch requests uid from the Graph. When tokens is set and the Graph
+// responds with a code-4 rate limit, the token is benched and the
+// request retried with the next available one instead of surfacing the
+// error to the caller. tokens.acquire blocks when every token is
+// cooling down, so this keeps retrying rather than giving up once it
+// has cycled through the pool once, which would otherwise drop a uid
+// that gets throttled mid-call.
+func (g graphFetcher) Fetch(uid uint64) (u *User, err error) {
+	if g.tokens == nil {
+		return g.fetchOnce(uid, "")
+	}
+
+	for {
+		token, acquireErr := g.tokens.acquire()
+		if acquireErr != nil {
+			return nil, acquireErr
+		}
+
+		u, err = g.fetchOnce(uid, token)
+		if ge, ok := err.(*graphError); ok && ge.Code == 4 {
+			g.tokens.cool(token, quotaCooldown)
+			continue
+		}
+		return u, err
+	}
+}
+
+// fetchOnce performs a single Graph request for uid using token (which
+// may be empty for an anonymous request), recording quota usage from the
+// response headers when a token pool is in play.
+func (g graphFetcher) fetchOnce(uid uint64, token string) (u *User, err error) {
+	var body []byte
+	var resp *http.Response
+	for attempt := 0; attempt < httpRetries; attempt++ {
+		resp, err = httpClient.Get(userUrl(uid, token))
+		if err != nil {
+			continue
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	if g.tokens != nil && token != "" {
+		g.tokens.recordUsage(token, resp)
+	}
+
+	gu := new(GraphUser)
+	if err = json.Unmarshal(body, &gu); err != nil {
+		return
+	}
+
+	u, err = gu.ToUser()
+	if err != nil {
+		return
+	}
+	u.Raw = body
+	return
+}
+
+// chainFallbackCodes are Graph error codes that mean the Graph endpoint
+// itself is unusable for this uid (no/expired token, missing
+// permission), as opposed to a bad uid or a rate limit, and so should
+// fall back to the browser fetcher instead of failing the uid outright.
+var chainFallbackCodes = map[int]bool{
+	190: true, // invalid OAuth access token
+	10:  true, // application does not have permission for this action
+}
+
+// chainFetcher tries primary first, falling back to secondary when
+// primary fails with one of chainFallbackCodes.
+type chainFetcher struct {
+	primary   Fetcher
+	secondary Fetcher
+}
+
+func (c chainFetcher) Fetch(uid uint64) (*User, error) {
+	u, err := c.primary.Fetch(uid)
+	if err == nil {
+		return u, nil
+	}
+
+	ge, ok := err.(*graphError)
+	if !ok || !chainFallbackCodes[ge.Code] {
+		return nil, err
+	}
+	return c.secondary.Fetch(uid)
+}
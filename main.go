@@ -3,15 +3,14 @@
 package main
 
 import (
-	"database/sql"
-	"encoding/json"
 	"flag"
 	"fmt"
-	_ "github.com/mattn/go-sqlite3"
-	"io/ioutil"
-	"log"
+	"io"
+	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"time"
 )
@@ -19,9 +18,40 @@ import (
 const dbFile = "fbgraph.db"
 const graphBase = "https://graph.facebook.com"
 
-// userUrl takes a user ID and returns the Facebook graph URL for that user.
-func userUrl(uid uint64) string {
-	return fmt.Sprintf("%s/%d", graphBase, uid)
+// httpClient is shared by every fetch so requests get a sane timeout
+// instead of hanging forever on a stalled connection.
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// httpRetries is the number of attempts graphFetcher will make for a
+// request that fails at the transport level (timeouts, connection resets)
+// before giving up. It does not apply to rate-limit errors, which are
+// handled by the shared backoff in the worker pool instead.
+const httpRetries = 3
+
+// graphError is returned when the Graph API responds with an error object
+// instead of user data, and preserves the error code so callers (notably
+// the worker pool's backoff logic) can tell a rate limit from a bad uid.
+type graphError struct {
+	Code    int
+	Type    string
+	Message string
+}
+
+func (e *graphError) Error() string {
+	return e.Message
+}
+
+// userUrl takes a user ID and returns the Facebook graph URL for that
+// user. If token is non-empty it is appended as the access_token query
+// parameter.
+func userUrl(uid uint64, token string) string {
+	u := fmt.Sprintf("%s/%d", graphBase, uid)
+	if token != "" {
+		u += "?access_token=" + url.QueryEscape(token)
+	}
+	return u
 }
 
 // Type GraphUser represents an entry from the Graph. It is not suitable
@@ -36,7 +66,20 @@ type GraphUser struct {
 	Username string `json:"username"`
 	Gender   string `json:"gender"`
 	Locale   string `json:"locale"`
-	Error    struct {
+	Bio      string `json:"bio"`
+	Verified bool   `json:"verified"`
+	Picture  struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	} `json:"picture"`
+	Hometown struct {
+		Name string `json:"name"`
+	} `json:"hometown"`
+	Location struct {
+		Name string `json:"name"`
+	} `json:"location"`
+	Error struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 		Code    int    `json:"code"`
@@ -54,7 +97,11 @@ func (gu *GraphUser) Failed() bool {
 // ToUser converts a GraphUser to a User.
 func (gu *GraphUser) ToUser() (u *User, err error) {
 	if gu.Failed() {
-		err = fmt.Errorf(gu.Error.Message)
+		err = &graphError{
+			Code:    gu.Error.Code,
+			Type:    gu.Error.Type,
+			Message: gu.Error.Message,
+		}
 		return
 	}
 	u = new(User)
@@ -78,13 +125,18 @@ func (gu *GraphUser) ToUser() (u *User, err error) {
 	u.Username = gu.Username
 	u.Gender = gu.Gender
 	u.Locale = gu.Locale
+	u.Bio = gu.Bio
+	u.Picture = gu.Picture.Data.URL
+	u.Hometown = gu.Hometown.Name
+	u.Location = gu.Location.Name
+	u.Verified = gu.Verified
 	return
 }
 
 // Type User is a representation of a graph user suitable for storing
 // in the database.
 type User struct {
-	Id       uint64
+	Id       uint64 `gorm:"primaryKey"`
 	Name     string
 	First    string
 	Last     string
@@ -92,152 +144,116 @@ type User struct {
 	Username string
 	Gender   string
 	Locale   string
+	Picture  string
+	Bio      string
+	Hometown string
+	Location string
+	Verified bool
+	// Raw holds the unmodified Graph response body, so fields the
+	// current schema doesn't parse out are still recoverable later.
+	Raw []byte `gorm:"column:raw"`
 }
 
-// Method Store is used to save a user to the database.
-func (u *User) Store() (err error) {
-	db, err := sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return
+// setupLogging installs the default slog handler for the process. format
+// is "text" or "json"; level is any value slog.Level accepts ("debug",
+// "info", "warn", "error").
+func setupLogging(format, level string) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		lvl = slog.LevelInfo
 	}
-	defer db.Close()
 
-	_, err = db.Exec(`insert into users values (?, ?, ?, ?, ?, ?, ?, ?)`,
-		u.Id, u.Name, u.First, u.Last, u.Link, u.Username, u.Gender,
-		u.Locale)
-	return
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(handler))
 }
 
-// checkDatabase looks for the database file, and makes sure it has the
-// appropriate table.
-func checkDatabase() {
-	db, err := sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return
-	}
-	defer db.Close()
+// fatal logs msg at error level and exits, mirroring log.Fatal now that
+// slog has no Fatal of its own.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	var missingTable = fmt.Errorf("no such table: users")
+// Download the graph!
+func main() {
+	fDB := flag.String("db", dbFile, "database DSN (sqlite path, or mysql://, postgres://)")
+	fWorkers := flag.Int("workers", 4, "number of concurrent fetch workers")
+	fStart := flag.Uint64("start", 0, "starting uid (0 resumes from the database)")
+	fEnd := flag.Uint64("end", math.MaxUint64, "ending uid, exclusive")
+	fFetcher := flag.String("fetcher", "graph", "fetch backend: graph, browser, or chain")
+	var fTokens tokenFlags
+	flag.Var(&fTokens, "token", "Graph access token (may be repeated)")
+	fTokensFile := flag.String("tokens-file", "", "file with one access token per line")
+	fLogFormat := flag.String("log-format", "text", "log output format: text or json")
+	fLogLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn, or error")
+	fMetricsAddr := flag.String("metrics-addr", "", "address to serve /metrics, /healthz, and /status on (disabled if empty)")
+	flag.Parse()
 
-	_, err = db.Exec("select count(*) from users")
-	if err != nil && err.Error() == missingTable.Error() {
-		fmt.Println("creating table")
-		err = createDB()
-	}
-	if err != nil {
-		panic("[!] fbgdl: opening profile database: " +
-			err.Error())
-	}
-}
+	setupLogging(*fLogFormat, *fLogLevel)
 
-// createDB is responsible for creating the database.
-func createDB() (err error) {
-	db, err := sql.Open("sqlite3", dbFile)
+	store, err := openStore(*fDB)
 	if err != nil {
-		return
+		fatal("opening store", "err", err)
 	}
-	defer db.Close()
-
-	_, err = db.Exec(`create table users
-                          (id integer primary key unique not null,
-                           name text,
-                           first text,
-                           last text,
-                           link text,
-                           username text,
-                           gender text,
-                           locale text)`)
-	return
-}
+	defer store.Close()
 
-func getLastUser() (count uint64, err error) {
-	db, err := sql.Open("sqlite3", dbFile)
-	if err != nil {
-		return
+	if err := store.Migrate(); err != nil {
+		fatal("running migrations", "err", err)
 	}
-	defer db.Close()
 
-	row := db.QueryRow("select count(*) from users")
-	err = row.Scan(&count)
+	resumeUid, err := store.LastID()
 	if err != nil {
-		return
-	}
-	if count == 0 {
-		return
+		fatal("reading last uid", "err", err)
 	}
 
-	row = db.QueryRow("select max(id) from users")
-	err = row.Scan(&count)
-	if err == nil {
-		count++
+	start := *fStart
+	if start == 0 {
+		start = resumeUid
 	}
-	return
-}
 
-// fetchUser grabs a user from the Graph, storing the user in the database
-// if it is a valid user. Otherwise, an error is returned.
-func fetchUser(uid uint64) (u *User, err error) {
-	resp, err := http.Get(userUrl(uid))
-	if err != nil {
-		return
+	if *fEnd < start {
+		fatal("end uid is less than start uid", "start", start, "end", *fEnd)
+	}
+	if *fWorkers < 1 {
+		fatal("workers must be at least 1", "workers", *fWorkers)
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
 
-	gu := new(GraphUser)
-	err = json.Unmarshal(body, &gu)
+	tokens, err := collectTokens(fTokens, *fTokensFile)
 	if err != nil {
-		return
+		fatal("collecting tokens", "err", err)
+	}
+	var pool *tokenPool
+	if len(tokens) > 0 {
+		pool = loadTokenPool(tokens, tokenStateFile)
 	}
 
-	u, err = gu.ToUser()
+	fetcher, err := newFetcher(*fFetcher, pool)
 	if err != nil {
-		return
+		fatal("building fetcher", "err", err)
+	}
+	if closer, ok := fetcher.(io.Closer); ok {
+		defer closer.Close()
 	}
 
-	err = u.Store()
-	return
-}
-
-// Download the graph!
-func main() {
-	checkDatabase()
+	if rows, err := store.RowCount(); err == nil {
+		dbRowsGauge.Set(float64(rows))
+	}
 
-	start, err := getLastUser()
-	if err != nil {
-		log.Fatal(err.Error())
+	st := newStatus(start, *fEnd)
+	if *fMetricsAddr != "" {
+		go serveMetrics(*fMetricsAddr, st)
 	}
 
-	fMaxUid := flag.Uint64("u", math.MaxUint64, "max uid to grab")
-	flag.Parse()
+	slog.Info("starting crawl", "start", start, "end", *fEnd, "workers", *fWorkers)
+	crawl(store, fetcher, start, *fEnd, *fWorkers, st)
 
-	if *fMaxUid < start {
-		log.Fatal("max uid is less than starting uid")
-	} else {
-		log.Printf("grabbing uids from %d to %d\n", start, *fMaxUid)
-	}
-
-	var ErrLimit = fmt.Errorf("(#4) Application request limit reached")
-	var total uint64
-	for uid := start; uid < *fMaxUid; uid++ {
-		u, err := fetchUser(uid)
-		if err != nil {
-			logMsg := fmt.Sprintf("failed uid %d: %s", uid,
-				err.Error())
-			log.Println(logMsg)
-			if err.Error() == ErrLimit.Error() {
-				uid--
-				<-time.After(1 * time.Hour)
-				continue
-			}
-		} else {
-			total++
-			logMsg := fmt.Sprintf("stored uid %d (%s)", uid,
-				u.Username)
-			log.Println(logMsg)
-			if total > 0 && total%1000 == 0 {
-				log.Printf("%d users stored\n", total)
-			}
-		}
+	if rows, err := store.RowCount(); err == nil {
+		dbRowsGauge.Set(float64(rows))
 	}
 }
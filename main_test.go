@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphUserToUser(t *testing.T) {
+	gu := &GraphUser{
+		Id:       "123456789",
+		Name:     "Jane Doe",
+		First:    "Jane",
+		Last:     "Doe",
+		Link:     "https://www.facebook.com/jane.doe",
+		Username: "jane.doe",
+		Gender:   "female",
+		Locale:   "en_US",
+		Bio:      "hello",
+		Verified: true,
+	}
+	gu.Picture.Data.URL = "https://example.com/pic.jpg"
+	gu.Hometown.Name = "Springfield"
+	gu.Location.Name = "Shelbyville"
+
+	u, err := gu.ToUser()
+	if err != nil {
+		t.Fatalf("ToUser: %v", err)
+	}
+
+	want := &User{
+		Id:       123456789,
+		Name:     "Jane Doe",
+		First:    "Jane",
+		Last:     "Doe",
+		Link:     "https://www.facebook.com/jane.doe",
+		Username: "jane.doe",
+		Gender:   "female",
+		Locale:   "en_US",
+		Bio:      "hello",
+		Picture:  "https://example.com/pic.jpg",
+		Hometown: "Springfield",
+		Location: "Shelbyville",
+		Verified: true,
+	}
+
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("ToUser() = %+v, want %+v", u, want)
+	}
+}
+
+func TestGraphUserToUserError(t *testing.T) {
+	gu := &GraphUser{}
+	gu.Error.Message = "Invalid OAuth access token"
+	gu.Error.Type = "OAuthException"
+	gu.Error.Code = 190
+
+	_, err := gu.ToUser()
+	if err == nil {
+		t.Fatal("ToUser: expected error, got nil")
+	}
+
+	ge, ok := err.(*graphError)
+	if !ok {
+		t.Fatalf("ToUser error type = %T, want *graphError", err)
+	}
+	if ge.Code != 190 || ge.Type != "OAuthException" {
+		t.Errorf("ToUser error = %+v, want code 190 / type OAuthException", ge)
+	}
+}
+
+func TestGraphUserToUserInvalidId(t *testing.T) {
+	gu := &GraphUser{Id: "not-a-number"}
+
+	if _, err := gu.ToUser(); err == nil {
+		t.Fatal("ToUser: expected error for non-numeric id, got nil")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	fetchedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fbgdl_fetched_total",
+		Help: "Total uids successfully fetched and stored.",
+	})
+	failedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fbgdl_failed_total",
+		Help: "Total uids that failed to fetch or store.",
+	})
+	rateLimitedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "fbgdl_rate_limited_total",
+		Help: "Total requests that hit a Graph rate limit.",
+	})
+	fetchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "fbgdl_fetch_duration_seconds",
+		Help:    "Graph/browser fetch request latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+	currentUidGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "fbgdl_current_uid",
+		Help: "The highest uid handed to a worker so far.",
+	})
+	dbRowsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "fbgdl_db_rows",
+		Help: "Number of rows currently in the users table.",
+	})
+)
+
+// statusSnapshot is the JSON shape served from /status.
+type statusSnapshot struct {
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end"`
+	Current uint64 `json:"current"`
+	Stored  uint64 `json:"stored"`
+	Failed  uint64 `json:"failed"`
+}
+
+// status tracks a crawl's live progress for /status, alongside the
+// package-level Prometheus metrics above.
+type status struct {
+	mu sync.Mutex
+	statusSnapshot
+}
+
+// newStatus returns a status for a crawl over [start, end).
+func newStatus(start, end uint64) *status {
+	return &status{statusSnapshot: statusSnapshot{Start: start, End: end}}
+}
+
+// setCurrent records uid as having been handed to a worker. With several
+// workers in flight, uids don't arrive in order, so this only ever moves
+// Current forward to the highest uid seen, matching currentUidGauge's
+// "highest uid handed to a worker so far" semantics.
+func (s *status) setCurrent(uid uint64) {
+	s.mu.Lock()
+	if uid > s.Current {
+		s.Current = uid
+	}
+	current := s.Current
+	s.mu.Unlock()
+	currentUidGauge.Set(float64(current))
+}
+
+func (s *status) incStored() {
+	s.mu.Lock()
+	s.Stored++
+	s.mu.Unlock()
+	fetchedTotal.Inc()
+}
+
+func (s *status) incFailed() {
+	s.mu.Lock()
+	s.Failed++
+	s.mu.Unlock()
+	failedTotal.Inc()
+}
+
+func (s *status) snapshot() statusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statusSnapshot
+}
+
+// serveMetrics starts an HTTP server on addr exposing Prometheus metrics
+// at /metrics, a liveness probe at /healthz, and the crawl's live
+// progress as JSON at /status. It blocks until the server exits, so
+// callers should run it in its own goroutine.
+func serveMetrics(addr string, st *status) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st.snapshot())
+	})
+
+	slog.Error("metrics server exited", "err", http.ListenAndServe(addr, mux))
+}
+
+// timeFetch runs fetch and records its duration in fetchDuration.
+func timeFetch(fetch func() (*User, error)) (*User, error) {
+	start := time.Now()
+	u, err := fetch()
+	fetchDuration.Observe(time.Since(start).Seconds())
+	return u, err
+}
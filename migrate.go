@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql migrations/mysql/*.sql
+var migrationFiles embed.FS
+
+// migrationDirs maps a gormStore driver name to the subdirectory of
+// migrations/ holding its schema, since column types that are portable on
+// one engine aren't always portable on another (e.g. sqlite/mysql "blob"
+// vs postgres "bytea").
+var migrationDirs = map[string]string{
+	"postgres": "migrations/postgres",
+	"mysql":    "migrations/mysql",
+	"sqlite3":  "migrations/sqlite",
+}
+
+// runMigrations applies any pending schema migrations for driver to
+// sqlDB, tracking the applied version in a schema_migrations table so it
+// is safe to call on every startup.
+func runMigrations(driver string, sqlDB *sql.DB) error {
+	dir, ok := migrationDirs[driver]
+	if !ok {
+		dir = migrationDirs["sqlite3"]
+	}
+
+	src, err := iofs.New(migrationFiles, dir)
+	if err != nil {
+		return err
+	}
+
+	var dbDriver database.Driver
+	switch driver {
+	case "postgres":
+		dbDriver, err = postgres.WithInstance(sqlDB, &postgres.Config{})
+	case "mysql":
+		dbDriver, err = mysql.WithInstance(sqlDB, &mysql.Config{})
+	default:
+		dbDriver, err = sqlite3.WithInstance(sqlDB, &sqlite3.Config{})
+	}
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, driver, dbDriver)
+	if err != nil {
+		return err
+	}
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+	return nil
+}
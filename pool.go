@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+)
+
+// crawl fans uids in [start, end) out across workers goroutines, each
+// pulling from a shared channel so a worker that finishes early picks up
+// the next free uid rather than sitting idle. A Ctrl-C stops the feed and
+// lets workers currently in flight finish and store their result before
+// crawl returns. st tracks live progress for the /status endpoint and
+// the fbgdl_* Prometheus metrics.
+func crawl(store Store, fetcher Fetcher, start, end uint64, workers int, st *status) {
+	uids := make(chan uint64, workers)
+	stop := make(chan struct{})
+	var total uint64
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		<-sigs
+		slog.Warn("interrupt received, finishing in-flight uids")
+		close(stop)
+	}()
+
+	bo := new(backoff)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for uid := range uids {
+				var u *User
+				var err error
+				for {
+					bo.wait()
+					st.setCurrent(uid)
+
+					u, err = timeFetch(func() (*User, error) {
+						return fetcher.Fetch(uid)
+					})
+					if err != nil && isRateLimited(err) {
+						rateLimitedTotal.Inc()
+						d := bo.trip()
+						slog.Warn("rate limited, retrying uid after backoff", "worker", id, "uid", uid, "backoff", d)
+						continue
+					}
+					break
+				}
+				if err != nil {
+					slog.Warn("fetch failed", "worker", id, "uid", uid, "err", err)
+					st.incFailed()
+					continue
+				}
+				if err := store.Upsert(u); err != nil {
+					slog.Warn("store failed", "worker", id, "uid", uid, "err", err)
+					st.incFailed()
+					continue
+				}
+				bo.reset()
+				st.incStored()
+				n := atomic.AddUint64(&total, 1)
+				slog.Info("stored uid", "worker", id, "uid", uid, "username", u.Username)
+				if n%1000 == 0 {
+					slog.Info("progress", "stored", n)
+					if rows, err := store.RowCount(); err == nil {
+						dbRowsGauge.Set(float64(rows))
+					}
+				}
+			}
+		}(i)
+	}
+
+feed:
+	for uid := start; uid < end; uid++ {
+		select {
+		case uids <- uid:
+		case <-stop:
+			break feed
+		}
+	}
+	close(uids)
+	wg.Wait()
+}
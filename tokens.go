@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenStateFile is where tokenPool persists cooldowns between runs, so a
+// restart doesn't immediately throw a cooling-down token back into
+// rotation and blow its quota.
+const tokenStateFile = "fbgdl-tokens.json"
+
+// quotaCooldown is how long a token is benched after recordUsage sees it
+// cross 90% of its quota, or after a code-4 rate-limit response.
+const quotaCooldown = 15 * time.Minute
+
+// tokenState is the persisted rotation state for a single access token.
+type tokenState struct {
+	Token         string    `json:"token"`
+	CooldownUntil time.Time `json:"cooldown_until,omitempty"`
+}
+
+// tokenPool rotates across a set of access tokens, handing graphFetcher
+// the next one that isn't cooling down, and benching tokens that hit a
+// rate limit or approach their quota instead of blocking the whole crawl.
+type tokenPool struct {
+	mu    sync.Mutex
+	state []*tokenState
+	next  int
+	path  string
+}
+
+// tokenFlags collects repeated -token flags into a slice.
+type tokenFlags []string
+
+func (t *tokenFlags) String() string { return strings.Join(*t, ",") }
+
+func (t *tokenFlags) Set(v string) error {
+	*t = append(*t, v)
+	return nil
+}
+
+// collectTokens merges tokens from -token, -tokens-file (one per line),
+// and the comma-separated FBGDL_TOKENS environment variable.
+func collectTokens(flagTokens []string, tokensFile string) ([]string, error) {
+	tokens := append([]string{}, flagTokens...)
+
+	if tokensFile != "" {
+		data, err := ioutil.ReadFile(tokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading tokens file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				tokens = append(tokens, line)
+			}
+		}
+	}
+
+	if env := os.Getenv("FBGDL_TOKENS"); env != "" {
+		for _, t := range strings.Split(env, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+// loadTokenPool builds a tokenPool for tokens, restoring any cooldowns
+// saved at path from a previous run.
+func loadTokenPool(tokens []string, path string) *tokenPool {
+	saved := make(map[string]time.Time)
+	if data, err := ioutil.ReadFile(path); err == nil {
+		var states []tokenState
+		if json.Unmarshal(data, &states) == nil {
+			for _, s := range states {
+				saved[s.Token] = s.CooldownUntil
+			}
+		}
+	}
+
+	p := &tokenPool{path: path}
+	for _, t := range tokens {
+		p.state = append(p.state, &tokenState{Token: t, CooldownUntil: saved[t]})
+	}
+	return p
+}
+
+// acquire returns the next token that isn't cooling down, round-robin. If
+// every token is currently cooling, it blocks until the earliest one
+// becomes available rather than returning an error that would send the
+// caller on to the next uid and lose this one permanently.
+func (p *tokenPool) acquire() (string, error) {
+	for {
+		p.mu.Lock()
+		if len(p.state) == 0 {
+			p.mu.Unlock()
+			return "", fmt.Errorf("token pool is empty")
+		}
+
+		now := time.Now()
+		var earliest time.Time
+		for i := 0; i < len(p.state); i++ {
+			idx := (p.next + i) % len(p.state)
+			s := p.state[idx]
+			if s.CooldownUntil.IsZero() || s.CooldownUntil.Before(now) {
+				p.next = idx + 1
+				p.mu.Unlock()
+				return s.Token, nil
+			}
+			if earliest.IsZero() || s.CooldownUntil.Before(earliest) {
+				earliest = s.CooldownUntil
+			}
+		}
+		p.mu.Unlock()
+
+		time.Sleep(time.Until(earliest))
+	}
+}
+
+// cool benches token for d, persisting the new state so a restart
+// respects it.
+func (p *tokenPool) cool(token string, d time.Duration) {
+	p.mu.Lock()
+	for _, s := range p.state {
+		if s.Token == token {
+			s.CooldownUntil = time.Now().Add(d)
+			break
+		}
+	}
+	p.mu.Unlock()
+	p.save()
+}
+
+// appUsage mirrors the JSON shape of the X-App-Usage and
+// X-Business-Use-Case-Usage response headers.
+type appUsage struct {
+	CallCount    int `json:"call_count"`
+	TotalTime    int `json:"total_time"`
+	TotalCPUTime int `json:"total_cputime"`
+}
+
+// recordUsage inspects resp's quota headers and preemptively cools token
+// if any tracked metric has crossed 90% of quota.
+func (p *tokenPool) recordUsage(token string, resp *http.Response) {
+	for _, header := range []string{"X-App-Usage", "X-Business-Use-Case-Usage"} {
+		raw := resp.Header.Get(header)
+		if raw == "" {
+			continue
+		}
+
+		var usage appUsage
+		if header == "X-Business-Use-Case-Usage" {
+			var byBusinessID map[string][]appUsage
+			if err := json.Unmarshal([]byte(raw), &byBusinessID); err != nil {
+				continue
+			}
+			for _, entries := range byBusinessID {
+				if len(entries) > 0 {
+					usage = entries[0]
+					break
+				}
+			}
+		} else if err := json.Unmarshal([]byte(raw), &usage); err != nil {
+			continue
+		}
+
+		if usage.CallCount >= 90 || usage.TotalTime >= 90 || usage.TotalCPUTime >= 90 {
+			p.cool(token, quotaCooldown)
+			return
+		}
+	}
+}
+
+// save persists the pool's cooldown state to disk.
+func (p *tokenPool) save() error {
+	p.mu.Lock()
+	states := make([]tokenState, len(p.state))
+	for i, s := range p.state {
+		states[i] = *s
+	}
+	p.mu.Unlock()
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p.path, data, 0600)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenPoolAcquireRotates(t *testing.T) {
+	p := &tokenPool{state: []*tokenState{
+		{Token: "a"},
+		{Token: "b"},
+		{Token: "c"},
+	}}
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		tok, err := p.acquire()
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		got = append(got, tok)
+	}
+
+	want := []string{"a", "b", "c"}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("acquire #%d = %q, want %q", i, got[i], w)
+		}
+	}
+}
+
+func TestTokenPoolAcquireSkipsCoolingTokens(t *testing.T) {
+	p := &tokenPool{state: []*tokenState{
+		{Token: "a", CooldownUntil: time.Now().Add(time.Hour)},
+		{Token: "b"},
+	}}
+
+	tok, err := p.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if tok != "b" {
+		t.Fatalf("acquire = %q, want %q", tok, "b")
+	}
+}
+
+func TestTokenPoolAcquireBlocksUntilCooldownExpires(t *testing.T) {
+	p := &tokenPool{state: []*tokenState{
+		{Token: "a", CooldownUntil: time.Now().Add(50 * time.Millisecond)},
+	}}
+
+	start := time.Now()
+	tok, err := p.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if tok != "a" {
+		t.Fatalf("acquire = %q, want %q", tok, "a")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("acquire returned after %v, want to block roughly until cooldown expired", elapsed)
+	}
+}
+
+func TestTokenPoolCool(t *testing.T) {
+	p := &tokenPool{
+		path:  t.TempDir() + "/tokens.json",
+		state: []*tokenState{{Token: "a"}, {Token: "b"}},
+	}
+
+	p.cool("a", time.Hour)
+
+	tok, err := p.acquire()
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if tok != "b" {
+		t.Fatalf("acquire after cooling %q = %q, want %q", "a", tok, "b")
+	}
+}